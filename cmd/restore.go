@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Emberwalker/tirisgarde/internal/archiver"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	restoreLatest       bool
+	restoreAt           string
+	restoreAccount      string
+	restoreDryRun       bool
+	restoreOverwrite    bool
+	restoreSkipExisting bool
+	restoreRenameSuffix string
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [archive]",
+	Short: "Restore a backup archive back into the WTF directory",
+	Long: `Extract a previously created backup archive back into --source/WTF.
+Pass an archive name explicitly, or select one with --latest/--at.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: restore,
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreLatest, "latest", false, "Restore the most recent archive")
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "Restore the most recent archive at or before this time (RFC3339, or local time without an offset, e.g. 2024-03-01T12:00:00)")
+	restoreCmd.Flags().StringVar(&restoreAccount, "account", "", "Restrict restoration to WTF/Account/<NAME>/")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "List what would be restored without writing any files")
+	restoreCmd.Flags().BoolVar(&restoreOverwrite, "overwrite", false, "Overwrite files that already exist at the destination")
+	restoreCmd.Flags().BoolVar(&restoreSkipExisting, "skip-existing", false, "Leave files that already exist at the destination untouched")
+	restoreCmd.Flags().StringVar(&restoreRenameSuffix, "rename-suffix", "", "Suffix inserted before the extension when a restored file already exists")
+
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	destPath := viper.GetString("dest")
+	backend, err := archiver.NewBackend(afero.NewOsFs(), destPath, backendConfigFromViper())
+	cobra.CheckErr(err)
+
+	archiveName, err := resolveArchive(backend, args)
+	cobra.CheckErr(err)
+
+	wtfDir := filepath.Join(sourcePath, "WTF")
+	log("Restoring %s into %s", archiveName, wtfDir)
+
+	a := archiver.NewArchiver(afero.NewOsFs(), backend, archiver.Options{})
+	err = a.Restore(afero.NewOsFs(), wtfDir, archiver.RestoreOptions{
+		Archive:      archiveName,
+		Account:      restoreAccount,
+		DryRun:       restoreDryRun,
+		Overwrite:    restoreOverwrite,
+		SkipExisting: restoreSkipExisting,
+		RenameSuffix: restoreRenameSuffix,
+	})
+	cobra.CheckErr(err)
+
+	log("Restore completed: %s", archiveName)
+	return nil
+}
+
+func resolveArchive(backend archiver.Backend, args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+
+	names, err := backend.List()
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case restoreLatest:
+		return archiver.LatestArchive(names)
+	case restoreAt != "":
+		at, err := time.Parse(time.RFC3339, restoreAt)
+		if err != nil {
+			at, err = time.ParseInLocation("2006-01-02T15:04:05", restoreAt, time.Local)
+		}
+		if err != nil {
+			return "", fmt.Errorf("parsing --at: %w", err)
+		}
+		return archiver.SelectArchive(names, at)
+	default:
+		return "", fmt.Errorf("specify an archive name, or pass --latest/--at")
+	}
+}
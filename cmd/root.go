@@ -1,21 +1,18 @@
 package cmd
 
 import (
-	"archive/zip"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/Emberwalker/tirisgarde/internal/archiver"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-const archiveNameTimeFormat = "2006-01-02_15-04-05.zip"
-
 var Version string = "devel"
 var sourcePath string
 
@@ -46,14 +43,29 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVarP(&sourcePath, "source", "i", ".", "WoW client directory (usually 'World of Warcraft/_retail_')")
-	rootCmd.PersistentFlags().StringP("dest", "o", filepath.Join(".", "WTF-Backup"), "Folder to store backups")
+	rootCmd.PersistentFlags().StringP("dest", "o", filepath.Join(".", "WTF-Backup"), "Where to store backups: a local folder, or a sftp:// or s3:// URI")
 	rootCmd.PersistentFlags().Uint("max-age", 30, "Max age for backups, in days")
+	rootCmd.PersistentFlags().Bool("incremental", false, "Skip re-archiving files unchanged since an earlier backup, using a content-addressed index at --dest")
+	rootCmd.PersistentFlags().String("compression", "deflate", "Zip compression method to use: deflate, store, or zstd")
+	rootCmd.PersistentFlags().Int("compression-level", 5, "Compression level, meaning depends on --compression (ignored for store)")
+	rootCmd.PersistentFlags().StringArray("include", nil, "Gitignore-style pattern to include (repeatable); if set, only matching files are archived")
+	rootCmd.PersistentFlags().StringArray("exclude", nil, "Gitignore-style pattern to exclude (repeatable)")
+
+	rootCmd.PersistentFlags().String("ssh-user", "", "Username for sftp:// destinations whose --dest URI has no user")
+	rootCmd.PersistentFlags().String("ssh-key", "", "Path to a private key for sftp:// destinations")
+	rootCmd.PersistentFlags().String("ssh-password", "", "Password for sftp:// destinations (falls back to TIRISGARDE_SSH_PASS)")
+
+	rootCmd.PersistentFlags().String("aws-access-key-id", "", "Access key ID for s3:// destinations")
+	rootCmd.PersistentFlags().String("aws-secret-access-key", "", "Secret access key for s3:// destinations")
+	rootCmd.PersistentFlags().String("aws-region", "", "Region for s3:// destinations")
+	rootCmd.PersistentFlags().String("aws-endpoint", "", "Custom endpoint for S3-compatible destinations (e.g. MinIO, Backblaze B2)")
 
 	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
 		if f.Name != "source" { // Skip the source, since that's always commandline-specified.
 			viper.BindPFlag(f.Name, f)
 		}
 	})
+	viper.BindEnv("ssh-password", "TIRISGARDE_SSH_PASS")
 }
 
 func initConfig() {
@@ -70,83 +82,48 @@ func initConfig() {
 	}
 }
 
-func archive(dest string, basePath string, files []string) error {
-	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return err
-	}
-
-	bar := progressbar.Default(int64(len(files)), filepath.Base(dest))
-	defer bar.Close()
-	zWrite := zip.NewWriter(f)
-	for _, file := range files {
-		relPath, err := filepath.Rel(basePath, file)
-		if err != nil {
-			return nil
-		}
-		entryWriter, err := zWrite.Create(relPath)
-		if err != nil {
-			return nil
-		}
-		contents, err := os.ReadFile(file)
-		if err != nil {
-			return nil
-		}
-		_, err = entryWriter.Write(contents)
-		if err != nil {
-			return nil
-		}
-		bar.Add(1)
-	}
-	bar.Finish()
+func backendConfigFromViper() archiver.BackendConfig {
+	return archiver.BackendConfig{
+		SSHUser:     viper.GetString("ssh-user"),
+		SSHKeyPath:  viper.GetString("ssh-key"),
+		SSHPassword: viper.GetString("ssh-password"),
 
-	if err = zWrite.Close(); err != nil {
-		return err
+		AWSAccessKeyID:     viper.GetString("aws-access-key-id"),
+		AWSSecretAccessKey: viper.GetString("aws-secret-access-key"),
+		AWSRegion:          viper.GetString("aws-region"),
+		AWSEndpoint:        viper.GetString("aws-endpoint"),
 	}
-	return f.Close()
 }
 
 func run(cmd *cobra.Command, args []string) error {
-	files := *new([]string)
+	srcFs := afero.NewOsFs()
 	wtfDir := filepath.Join(sourcePath, "WTF")
-	err := filepath.WalkDir(wtfDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if !d.IsDir() {
-			files = append(files, path)
-		}
-		return nil
-	})
-	cobra.CheckErr(err)
-	log("%v files to archive", len(files))
 
 	destPath := viper.GetString("dest")
-	archiveName := time.Now().Format(archiveNameTimeFormat)
-	archivePath := filepath.Join(destPath, archiveName)
-
-	log("Archiving to: %v", archivePath)
-	cobra.CheckErr(os.MkdirAll(destPath, os.ModeDir))
-	cobra.CheckErr(archive(archivePath, wtfDir, files))
-	log("Archive completed: %v", archivePath)
+	backend, err := archiver.NewBackend(afero.NewOsFs(), destPath, backendConfigFromViper())
+	cobra.CheckErr(err)
 
 	maxDays := viper.GetUint("max-age")
-	maxHrs := maxDays * 24
+	include := viper.GetStringSlice("include")
+	exclude := viper.GetStringSlice("exclude")
+	log("Effective include/exclude filters: %s", archiver.NewFilter(include, exclude))
+
+	a := archiver.NewArchiver(srcFs, backend, archiver.Options{
+		MaxAge:           time.Duration(maxDays) * 24 * time.Hour,
+		Incremental:      viper.GetBool("incremental"),
+		Compression:      archiver.Compression(viper.GetString("compression")),
+		CompressionLevel: viper.GetInt("compression-level"),
+		Include:          include,
+		Exclude:          exclude,
+	})
+
+	archiveName := time.Now().Format(archiver.ArchiveNameTimeFormat)
+	log("Archiving to: %v (%v)", destPath, archiveName)
+	cobra.CheckErr(a.Archive(wtfDir, archiveName))
+	log("Archive completed: %v", archiveName)
+
 	log("Pruning backups older than %v days...", maxDays)
-	delta, _ := time.ParseDuration(fmt.Sprintf("%vh", maxHrs))
-	cutoff := time.Now().Add(-delta)
-	zips, err := filepath.Glob(filepath.Join(destPath, "*.zip"))
-	cobra.CheckErr(err)
-	for _, path := range zips {
-		ts, err := time.ParseInLocation(archiveNameTimeFormat, filepath.Base(path), time.Local)
-		if err != nil {
-			continue // Skip non-matching files
-		}
-		if ts.Before(cutoff) {
-			log("Pruning: %s", path)
-			os.Remove(path)
-		}
-	}
+	cobra.CheckErr(a.Prune(time.Now()))
 
 	return nil
 }
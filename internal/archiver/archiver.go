@@ -0,0 +1,297 @@
+// Package archiver implements the WTF walk/zip/prune logic behind
+// tirisgarde, decoupled from cobra/viper so it can be exercised with an
+// in-memory afero.Fs in tests instead of the real disk.
+package archiver
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/afero"
+)
+
+// ArchiveNameTimeFormat is the filename (and zip.Writer time.Format) used
+// for archives, and is also what prune parses to find an archive's age.
+const ArchiveNameTimeFormat = "2006-01-02_15-04-05.zip"
+
+// Options configures an Archiver.
+type Options struct {
+	// MaxAge is how long a completed archive is kept before Prune removes it.
+	MaxAge time.Duration
+	// Incremental, when set, skips re-zipping files whose content hash is
+	// already present in an earlier archive, tracked via a content-addressed
+	// index kept at the destination.
+	Incremental bool
+	// Compression selects the zip method used for new entries. Defaults to
+	// CompressionDeflate.
+	Compression Compression
+	// CompressionLevel is passed through to the selected compressor; its
+	// meaning depends on Compression (ignored for CompressionStore).
+	CompressionLevel int
+	// Include, if non-empty, restricts archiving to files matching at least
+	// one gitignore-style pattern. Exclude removes files/directories
+	// matching any pattern, and is applied whether or not Include is set.
+	Include []string
+	Exclude []string
+}
+
+// Archiver walks a source tree and produces zip archives on a Backend,
+// pruning old ones afterwards.
+type Archiver struct {
+	srcFs afero.Fs
+	dest  Backend
+	opts  Options
+}
+
+// NewArchiver builds an Archiver that reads files via srcFs and writes/prunes
+// archives via dest.
+func NewArchiver(srcFs afero.Fs, dest Backend, opts Options) *Archiver {
+	return &Archiver{srcFs: srcFs, dest: dest, opts: opts}
+}
+
+// walk collects every regular file under root that filter allows,
+// pruning excluded directories entirely rather than descending into them.
+func (a *Archiver) walk(root string, filter *Filter) ([]string, error) {
+	var files []string
+	err := afero.Walk(a.srcFs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			// Only Exclude can prune a directory outright: Include patterns
+			// are typically leaf-file globs (e.g. "**/SavedVariables/*.lua")
+			// that would never match an intermediate directory, and pruning
+			// on that basis would stop the walk before it ever reached a
+			// matching file further down.
+			if filter.excludes(relPath + "/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filter.Allows(relPath) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Archive walks root and streams a zip of every file under it that the
+// Include/Exclude filter allows to the destination backend as archiveName, without holding the whole tree in
+// memory at once.
+//
+// When Options.Incremental is set, files whose content hash already
+// exists in an earlier archive are referenced rather than re-zipped: the
+// embedded manifest records which archive actually holds their bytes, and
+// the destination's content-addressed index is updated accordingly.
+func (a *Archiver) Archive(root string, archiveName string) error {
+	filter := NewFilter(a.opts.Include, a.opts.Exclude)
+
+	files, err := a.walk(root, filter)
+	if err != nil {
+		return err
+	}
+
+	method, err := a.opts.Compression.method(a.opts.CompressionLevel)
+	if err != nil {
+		return err
+	}
+
+	idx := index{}
+	if a.opts.Incremental {
+		idx, err = loadIndex(a.dest)
+		if err != nil {
+			return fmt.Errorf("loading incremental index: %w", err)
+		}
+	}
+
+	pr, pw := io.Pipe()
+	bar := progressbar.Default(int64(len(files)), archiveName)
+	defer bar.Close()
+
+	manifest := make([]ManifestEntry, 0, len(files))
+
+	go func() {
+		zWrite := zip.NewWriter(pw)
+		zWrite.SetComment(fmt.Sprintf("tirisgarde archive; compression=%s level=%d; filters=%s", a.opts.Compression.orDefault(), a.opts.CompressionLevel, filter.String()))
+
+		for _, file := range files {
+			relPath, err := filepath.Rel(root, file)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			info, err := a.srcFs.Stat(file)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			hash, err := hashFile(a.srcFs, file)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			entry := ManifestEntry{
+				RelPath:       relPath,
+				SHA256:        hash,
+				Size:          info.Size(),
+				ModTime:       info.ModTime(),
+				SourceArchive: archiveName,
+				SourcePath:    relPath,
+			}
+
+			if a.opts.Incremental {
+				if existing, ok := idx[hash]; ok {
+					entry.SourceArchive = existing.Archive
+					entry.SourcePath = existing.Path
+					manifest = append(manifest, entry)
+					bar.Add(1)
+					continue // Unchanged since an earlier archive; reference it instead of re-zipping.
+				}
+				idx[hash] = indexEntry{Archive: archiveName, Path: relPath}
+			}
+
+			if err := copyFileIntoZip(a.srcFs, file, zWrite, relPath, method, info); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			manifest = append(manifest, entry)
+			bar.Add(1)
+		}
+
+		if a.opts.Incremental {
+			garbageCollectIndex(idx, manifest)
+		}
+
+		manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		manifestWriter, err := zWrite.Create(manifestFileName)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err = manifestWriter.Write(manifestJSON); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if err := zWrite.Close(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.Close()
+	}()
+
+	if err := a.dest.Put(archiveName, pr); err != nil {
+		return err
+	}
+	bar.Finish()
+
+	if a.opts.Incremental {
+		if err := saveIndex(a.dest, idx); err != nil {
+			return fmt.Errorf("saving incremental index: %w", err)
+		}
+	}
+	return nil
+}
+
+// hashFile streams path through SHA-256 without holding its contents in
+// memory, so even the tens-of-megabyte SavedVariables files some addons
+// produce cost only a read buffer's worth of RAM.
+func hashFile(fs afero.Fs, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFileIntoZip streams path into a new entry of zWrite using io.Copy,
+// rather than reading the whole file into memory first.
+func copyFileIntoZip(fs afero.Fs, path string, zWrite *zip.Writer, relPath string, method uint16, info os.FileInfo) error {
+	src, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	entryWriter, err := zWrite.CreateHeader(&zip.FileHeader{
+		Name:     relPath,
+		Method:   method,
+		Modified: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entryWriter, src)
+	return err
+}
+
+// Prune removes archives from the destination older than Options.MaxAge,
+// relative to now. In incremental mode this is a mark-and-sweep over the
+// content-addressed index: any archive still referenced as the source of
+// a live hash is kept regardless of age, since deleting it would corrupt
+// every later archive's manifest.
+func (a *Archiver) Prune(now time.Time) error {
+	names, err := a.dest.List()
+	if err != nil {
+		return err
+	}
+
+	inUse := map[string]bool{}
+	if a.opts.Incremental {
+		idx, err := loadIndex(a.dest)
+		if err != nil {
+			return fmt.Errorf("loading incremental index: %w", err)
+		}
+		for _, entry := range idx {
+			inUse[entry.Archive] = true
+		}
+	}
+
+	cutoff := now.Add(-a.opts.MaxAge)
+	for _, name := range names {
+		ts, err := time.ParseInLocation(ArchiveNameTimeFormat, name, time.Local)
+		if err != nil {
+			continue // Skip non-matching files
+		}
+		if !ts.Before(cutoff) {
+			continue
+		}
+		if inUse[name] {
+			continue // Still referenced by the incremental index.
+		}
+		if err := a.dest.Delete(name); err != nil {
+			return fmt.Errorf("pruning %s: %w", name, err)
+		}
+	}
+	return nil
+}
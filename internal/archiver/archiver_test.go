@@ -0,0 +1,296 @@
+package archiver
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// memBackend is a minimal in-memory Backend for exercising Archive/Prune
+// without touching the disk or network.
+type memBackend struct {
+	archives map[string][]byte
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{archives: map[string][]byte{}}
+}
+
+func (b *memBackend) List() ([]string, error) {
+	names := make([]string, 0, len(b.archives))
+	for name := range b.archives {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (b *memBackend) Put(name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b.archives[name] = data
+	return nil
+}
+
+func (b *memBackend) Get(name string) (io.ReadCloser, error) {
+	data, ok := b.archives[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *memBackend) Delete(name string) error {
+	delete(b.archives, name)
+	return nil
+}
+
+func TestArchiveWritesExpectedEntries(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = {}"), 0644)
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/config-cache.wtf", []byte("config"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 30 * 24 * time.Hour})
+
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+
+	data, ok := dest.archives["2024-01-01_00-00-00.zip"]
+	if !ok {
+		t.Fatalf("expected archive to be stored on the backend")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading produced zip: %v", err)
+	}
+	want := map[string]bool{
+		"Account/TEST/SavedVariables/Addon.lua": false,
+		"Account/TEST/config-cache.wtf":         false,
+	}
+	for _, f := range zr.File {
+		if f.Name == manifestFileName {
+			continue
+		}
+		if _, ok := want[f.Name]; !ok {
+			t.Errorf("unexpected entry in archive: %s", f.Name)
+		}
+		want[f.Name] = true
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("expected entry missing from archive: %s", name)
+		}
+	}
+}
+
+func TestArchiveWithIncludeDescendsIntoNonMatchingDirectories(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = {}"), 0644)
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/config-cache.wtf", []byte("config"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{
+		MaxAge:  30 * 24 * time.Hour,
+		Include: []string{"**/SavedVariables/*.lua"},
+	})
+
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+
+	data := dest.archives["2024-01-01_00-00-00.zip"]
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading produced zip: %v", err)
+	}
+
+	found := false
+	for _, f := range zr.File {
+		if f.Name == "Account/TEST/config-cache.wtf" {
+			t.Errorf("non-matching file should have been excluded by --include")
+		}
+		if f.Name == "Account/TEST/SavedVariables/Addon.lua" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected Addon.lua to survive --include despite living several directories deep")
+	}
+}
+
+func TestArchiveWithStoreCompressionRoundTrips(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = { hello = 'world' }"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 30 * 24 * time.Hour, Compression: CompressionStore})
+
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+
+	data := dest.archives["2024-01-01_00-00-00.zip"]
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading produced zip: %v", err)
+	}
+
+	for _, f := range zr.File {
+		if f.Name == manifestFileName {
+			continue
+		}
+		if f.Method != zip.Store {
+			t.Errorf("expected entry %s to use the store method, got %d", f.Name, f.Method)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening entry: %v", err)
+		}
+		defer rc.Close()
+		contents, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("reading entry: %v", err)
+		}
+		if string(contents) != "saved = { hello = 'world' }" {
+			t.Errorf("unexpected contents for %s: %q", f.Name, contents)
+		}
+	}
+}
+
+func TestIncrementalArchiveSkipsUnchangedFiles(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = {}"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 30 * 24 * time.Hour, Incremental: true})
+
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("first Archive() error = %v", err)
+	}
+	if err := a.Archive("/WTF", "2024-01-02_00-00-00.zip"); err != nil {
+		t.Fatalf("second Archive() error = %v", err)
+	}
+
+	data := dest.archives["2024-01-02_00-00-00.zip"]
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading second zip: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name == "Account/TEST/SavedVariables/Addon.lua" {
+			t.Errorf("unchanged file should not be re-archived, found %s in second archive", f.Name)
+		}
+	}
+
+	var manifest []ManifestEntry
+	for _, f := range zr.File {
+		if f.Name != manifestFileName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening manifest: %v", err)
+		}
+		defer rc.Close()
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			t.Fatalf("decoding manifest: %v", err)
+		}
+	}
+	if len(manifest) != 1 || manifest[0].SourceArchive != "2024-01-01_00-00-00.zip" {
+		t.Errorf("expected manifest to reference the first archive, got %+v", manifest)
+	}
+}
+
+func TestPruneKeepsArchivesReferencedByIncrementalIndex(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = {}"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 1 * time.Hour, Incremental: true})
+
+	if err := a.Archive("/WTF", "2020-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	if err := a.Archive("/WTF", "2020-01-02_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	if err := a.Prune(now); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if _, ok := dest.archives["2020-01-01_00-00-00.zip"]; !ok {
+		t.Errorf("archive still referenced by the index should not be pruned")
+	}
+}
+
+func TestPruneRemovesArchivesWhoseContentHasBeenSuperseded(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	path := "/WTF/Account/TEST/SavedVariables/Addon.lua"
+	afero.WriteFile(srcFs, path, []byte("v1"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 1 * time.Hour, Incremental: true})
+
+	// Archive A holds only the file's v1 content.
+	if err := a.Archive("/WTF", "2020-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() A error = %v", err)
+	}
+
+	// The file changes; archive B holds the new v2 content.
+	afero.WriteFile(srcFs, path, []byte("v2"), 0644)
+	if err := a.Archive("/WTF", "2020-01-02_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() B error = %v", err)
+	}
+
+	// Two more archives both source the current v2 content from B.
+	if err := a.Archive("/WTF", "2020-01-03_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() C error = %v", err)
+	}
+	if err := a.Archive("/WTF", "2020-01-04_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() D error = %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	if err := a.Prune(now); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if _, ok := dest.archives["2020-01-01_00-00-00.zip"]; ok {
+		t.Errorf("archive A's v1 content was superseded and nothing references it any more; it should have been pruned")
+	}
+	if _, ok := dest.archives["2020-01-02_00-00-00.zip"]; !ok {
+		t.Errorf("archive B should survive: later archives still source their content from it")
+	}
+}
+
+func TestPruneRemovesOnlyOldArchives(t *testing.T) {
+	dest := newMemBackend()
+	dest.archives["2020-01-01_00-00-00.zip"] = []byte("old")
+	dest.archives["2099-01-01_00-00-00.zip"] = []byte("new")
+
+	a := NewArchiver(afero.NewMemMapFs(), dest, Options{MaxAge: 30 * 24 * time.Hour})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	if err := a.Prune(now); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if _, ok := dest.archives["2020-01-01_00-00-00.zip"]; ok {
+		t.Errorf("expected old archive to be pruned")
+	}
+	if _, ok := dest.archives["2099-01-01_00-00-00.zip"]; !ok {
+		t.Errorf("expected new archive to survive pruning")
+	}
+}
@@ -0,0 +1,89 @@
+package archiver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// remoteNotFoundErr stands in for the transport-specific "not found" errors
+// sftp/s3 actually return (*sftp.StatusError, *types.NoSuchKey): neither is
+// os.ErrNotExist, nor unwraps to it via the legacy os.IsNotExist check, only
+// via errors.Is against the fs.ErrNotExist a well-behaved Get should wrap it in.
+type remoteNotFoundErr struct{}
+
+func (remoteNotFoundErr) Error() string { return "remote: no such file" }
+
+// remoteBackend is a minimal Backend whose Get mimics sftpBackend/s3Backend:
+// it maps its transport's not-found error to a wrapped fs.ErrNotExist rather
+// than returning os.ErrNotExist bare.
+type remoteBackend struct{ *memBackend }
+
+func (b *remoteBackend) Get(name string) (io.ReadCloser, error) {
+	r, err := b.memBackend.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", name, fs.ErrNotExist, remoteNotFoundErr{})
+	}
+	return r, nil
+}
+
+func TestLoadIndexTreatsWrappedNotExistAsEmpty(t *testing.T) {
+	dest := &remoteBackend{memBackend: newMemBackend()}
+
+	idx, err := loadIndex(dest)
+	if err != nil {
+		t.Fatalf("loadIndex() error = %v", err)
+	}
+	if len(idx) != 0 {
+		t.Errorf("loadIndex() = %v, want empty index", idx)
+	}
+}
+
+func TestLoadIndexPropagatesOtherErrors(t *testing.T) {
+	dest := &failingBackend{err: errors.New("connection reset")}
+
+	if _, err := loadIndex(dest); err == nil {
+		t.Error("expected loadIndex() to propagate a non-not-found error")
+	}
+}
+
+// failingBackend's Get always fails with a non-not-found error.
+type failingBackend struct {
+	*memBackend
+	err error
+}
+
+func (b *failingBackend) Get(name string) (io.ReadCloser, error) {
+	return nil, b.err
+}
+
+func TestNewBackendTreatsWindowsDrivePathsAsLocal(t *testing.T) {
+	backend, err := NewBackend(afero.NewMemMapFs(), `D:\Backups\WoW`, BackendConfig{})
+	if err != nil {
+		t.Fatalf("NewBackend() error = %v", err)
+	}
+	if _, ok := backend.(*localBackend); !ok {
+		t.Errorf("expected a Windows drive path to resolve to a local backend, got %T", backend)
+	}
+}
+
+func TestNewBackendTreatsPlainPathAsLocal(t *testing.T) {
+	backend, err := NewBackend(afero.NewMemMapFs(), "./WTF-Backup", BackendConfig{})
+	if err != nil {
+		t.Fatalf("NewBackend() error = %v", err)
+	}
+	if _, ok := backend.(*localBackend); !ok {
+		t.Errorf("expected a plain relative path to resolve to a local backend, got %T", backend)
+	}
+}
+
+func TestNewBackendRejectsUnknownScheme(t *testing.T) {
+	_, err := NewBackend(afero.NewMemMapFs(), "ftp://example.com/backups", BackendConfig{})
+	if err == nil {
+		t.Errorf("expected an unsupported scheme to be rejected")
+	}
+}
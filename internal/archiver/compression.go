@@ -0,0 +1,115 @@
+package archiver
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zipMethodZstd is an unofficial zip method id for Zstandard entries,
+// following the convention used by klauspost/compress's own zip examples.
+// Only tirisgarde (or other klauspost-aware tools) will understand
+// archives written with it; mainstream zip tools will not.
+const zipMethodZstd uint16 = 93
+
+// Compression selects the zip compression method used when writing new
+// archive entries.
+type Compression string
+
+const (
+	CompressionDeflate Compression = "deflate"
+	CompressionStore   Compression = "store"
+	CompressionZstd    Compression = "zstd"
+)
+
+func init() {
+	// Registered unconditionally (rather than only when writing zstd
+	// archives) so that restoring an archive written with zstd works
+	// regardless of what --compression the current run was started with.
+	zip.RegisterDecompressor(zipMethodZstd, func(r io.Reader) io.ReadCloser {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return io.NopCloser(&errReader{err})
+		}
+		return zr.IOReadCloser()
+	})
+}
+
+// registerZstdCompressor is called at most once per process: RegisterCompressor
+// panics if the same method id is registered twice, which a second Archive()
+// call (or a second test) in the same process would otherwise trigger.
+var registerZstdCompressor = sync.OnceFunc(func() {
+	zip.RegisterCompressor(zipMethodZstd, func(out io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(out, zstd.WithEncoderLevel(zstdEncoderLevel(defaultZstdLevel)))
+	})
+})
+
+// defaultZstdLevel carries --compression-level into the zstd factory, since
+// RegisterCompressor's factory takes no per-call arguments. method() sets it
+// on every call and the factory reads it each time it creates a writer.
+var defaultZstdLevel int
+
+// registerDeflateCompressor overrides archive/zip's builtin zip.Deflate
+// compressor (registered at the stdlib's fixed default level) with one that
+// honors --compression-level, for the same once-per-process reason as
+// registerZstdCompressor above.
+var registerDeflateCompressor = sync.OnceFunc(func() {
+	zip.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, defaultDeflateLevel)
+	})
+})
+
+// defaultDeflateLevel carries --compression-level into the deflate factory,
+// in the same way as defaultZstdLevel.
+var defaultDeflateLevel int
+
+type errReader struct{ err error }
+
+func (e *errReader) Read([]byte) (int, error) { return 0, e.err }
+
+// method returns the zip method id to use in the zip.FileHeader for new
+// entries. Both compressors are registered lazily, once per process, since
+// RegisterCompressor panics if the same method id is registered twice.
+func (c Compression) method(level int) (uint16, error) {
+	switch c {
+	case "", CompressionDeflate:
+		defaultDeflateLevel = level
+		registerDeflateCompressor()
+		return zip.Deflate, nil
+	case CompressionStore:
+		return zip.Store, nil
+	case CompressionZstd:
+		defaultZstdLevel = level
+		registerZstdCompressor()
+		return zipMethodZstd, nil
+	default:
+		return 0, fmt.Errorf("unknown --compression %q (want deflate, store, or zstd)", c)
+	}
+}
+
+// orDefault returns c, or CompressionDeflate if c is unset.
+func (c Compression) orDefault() Compression {
+	if c == "" {
+		return CompressionDeflate
+	}
+	return c
+}
+
+// zstdEncoderLevel maps the numeric --compression-level scale (as used
+// for deflate) onto zstd's named speed/ratio presets.
+func zstdEncoderLevel(level int) zstd.EncoderLevel {
+	switch {
+	case level <= 1:
+		return zstd.SpeedFastest
+	case level <= 3:
+		return zstd.SpeedDefault
+	case level <= 6:
+		return zstd.SpeedBetterCompression
+	default:
+		return zstd.SpeedBestCompression
+	}
+}
@@ -0,0 +1,75 @@
+package archiver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// Filter decides which files under a walked root are archived, using
+// gitignore-style include/exclude patterns.
+type Filter struct {
+	includePatterns []string
+	excludePatterns []string
+	include         *gitignore.GitIgnore
+	exclude         *gitignore.GitIgnore
+}
+
+// NewFilter compiles include/exclude pattern lists into a Filter. An empty
+// include list means "everything is included" (subject to exclude); an
+// empty exclude list excludes nothing.
+func NewFilter(include, exclude []string) *Filter {
+	f := &Filter{includePatterns: include, excludePatterns: exclude}
+	if len(include) > 0 {
+		f.include = gitignore.CompileIgnoreLines(include...)
+	}
+	if len(exclude) > 0 {
+		f.exclude = gitignore.CompileIgnoreLines(exclude...)
+	}
+	return f
+}
+
+// Allows reports whether relPath (relative to the archive root) should be
+// archived. Pass a trailing slash for directories so directory-only
+// patterns (e.g. "cache/") match as they would in a .gitignore.
+func (f *Filter) Allows(relPath string) bool {
+	if f == nil {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	if f.exclude != nil && f.exclude.MatchesPath(relPath) {
+		return false
+	}
+	if f.include != nil && !f.include.MatchesPath(relPath) {
+		return false
+	}
+	return true
+}
+
+// excludes reports whether relPath matches an Exclude pattern. Unlike
+// Allows, it ignores Include, since Include patterns are leaf-file globs
+// that can't be used to decide whether to prune a directory.
+func (f *Filter) excludes(relPath string) bool {
+	if f == nil || f.exclude == nil {
+		return false
+	}
+	return f.exclude.MatchesPath(filepath.ToSlash(relPath))
+}
+
+// String renders the effective ruleset, for logging and for embedding in
+// archive comments so a given backup's filtering is reproducible.
+func (f *Filter) String() string {
+	if f == nil || (len(f.includePatterns) == 0 && len(f.excludePatterns) == 0) {
+		return "(none)"
+	}
+	var parts []string
+	if len(f.includePatterns) > 0 {
+		parts = append(parts, fmt.Sprintf("include=[%s]", strings.Join(f.includePatterns, ", ")))
+	}
+	if len(f.excludePatterns) > 0 {
+		parts = append(parts, fmt.Sprintf("exclude=[%s]", strings.Join(f.excludePatterns, ", ")))
+	}
+	return strings.Join(parts, " ")
+}
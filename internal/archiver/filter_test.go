@@ -0,0 +1,35 @@
+package archiver
+
+import "testing"
+
+func TestFilterExcludePrunesMatchingPaths(t *testing.T) {
+	f := NewFilter(nil, []string{"**/WeakAuras/cache/**"})
+
+	if f.Allows("Account/TEST/SavedVariables/WeakAuras/cache/") {
+		t.Errorf("expected the cache directory itself to be excluded")
+	}
+	if f.Allows("Account/TEST/SavedVariables/WeakAuras/cache/blob.lua") {
+		t.Errorf("expected a file under the excluded directory to be excluded")
+	}
+	if !f.Allows("Account/TEST/SavedVariables/WeakAuras.lua") {
+		t.Errorf("expected an unrelated file to still be allowed")
+	}
+}
+
+func TestFilterIncludeRestrictsToMatchingPaths(t *testing.T) {
+	f := NewFilter([]string{"**/SavedVariables/*.lua"}, nil)
+
+	if !f.Allows("Account/TEST/SavedVariables/Addon.lua") {
+		t.Errorf("expected a matching lua file to be included")
+	}
+	if f.Allows("Account/TEST/config-cache.wtf") {
+		t.Errorf("expected a non-matching file to be excluded")
+	}
+}
+
+func TestNilFilterAllowsEverything(t *testing.T) {
+	var f *Filter
+	if !f.Allows("anything") {
+		t.Errorf("a nil filter should allow everything")
+	}
+}
@@ -0,0 +1,92 @@
+package archiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// indexFileName is where the content-addressed hash -> archive/entry
+// mapping is kept at the destination, so incremental runs can skip
+// re-zipping files that haven't changed since an earlier backup.
+const indexFileName = ".tirisgarde-index.json"
+
+// manifestFileName is the name of the per-run manifest embedded in every
+// archive, listing every file it logically contains, whether its bytes
+// live in this archive or an earlier one.
+const manifestFileName = "_manifest.json"
+
+// indexEntry records where the bytes for a given content hash currently live.
+type indexEntry struct {
+	Archive string `json:"archive"`
+	Path    string `json:"path"`
+}
+
+// index maps a file's SHA-256 (hex-encoded) to the archive/entry holding
+// its bytes.
+type index map[string]indexEntry
+
+func loadIndex(dest Backend) (index, error) {
+	r, err := dest.Get(indexFileName)
+	if err != nil {
+		// errors.Is (not the legacy os.IsNotExist, which doesn't unwrap)
+		// since remote backends wrap their own not-found errors in
+		// fs.ErrNotExist rather than returning it bare.
+		if errors.Is(err, fs.ErrNotExist) {
+			return index{}, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	var idx index
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", indexFileName, err)
+	}
+	return idx, nil
+}
+
+func saveIndex(dest Backend, idx index) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return dest.Put(indexFileName, bytes.NewReader(data))
+}
+
+// garbageCollectIndex removes idx entries whose hash no longer appears in
+// manifest, the just-written list of every file Archive currently knows
+// about. Without this, a hash superseded by a file's later edit stays in
+// idx forever, and Prune's mark-and-sweep would keep treating the archive
+// it points at as in use long after nothing actually references it.
+func garbageCollectIndex(idx index, manifest []ManifestEntry) {
+	live := make(map[string]bool, len(manifest))
+	for _, entry := range manifest {
+		live[entry.SHA256] = true
+	}
+	for hash := range idx {
+		if !live[hash] {
+			delete(idx, hash)
+		}
+	}
+}
+
+// ManifestEntry describes a single file as captured in a backup run,
+// regardless of whether its bytes were newly written to this archive or
+// are only referenced from an earlier one.
+type ManifestEntry struct {
+	RelPath       string    `json:"relpath"`
+	SHA256        string    `json:"sha256"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mtime"`
+	SourceArchive string    `json:"source_archive"`
+	// SourcePath is the entry name the bytes are actually stored under in
+	// SourceArchive. It differs from RelPath whenever this file's content
+	// was deduplicated against a *different* path that first brought that
+	// hash into the index (e.g. two characters sharing an unmodified addon
+	// default), so restore must extract by SourcePath, not RelPath.
+	SourcePath string `json:"source_path"`
+}
@@ -0,0 +1,267 @@
+package archiver
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/afero"
+)
+
+// RestoreOptions configures a Restore call.
+type RestoreOptions struct {
+	// Archive is the name of the archive to restore from (see LatestArchive/SelectArchive).
+	Archive string
+	// Account, if set, restricts extraction to files under Account/<Account>/.
+	Account string
+	// DryRun logs what would be extracted without writing anything.
+	DryRun bool
+	// Overwrite replaces files that already exist at the destination.
+	Overwrite bool
+	// SkipExisting leaves files that already exist at the destination untouched.
+	SkipExisting bool
+	// RenameSuffix, if set, is inserted before the extension of a restored
+	// file's name when the destination already exists.
+	RenameSuffix string
+}
+
+// LatestArchive returns the most recent archive name, by the timestamp
+// encoded in its filename.
+func LatestArchive(names []string) (string, error) {
+	sorted := sortedArchiveNames(names)
+	if len(sorted) == 0 {
+		return "", fmt.Errorf("no archives found")
+	}
+	return sorted[len(sorted)-1], nil
+}
+
+// SelectArchive returns the most recent archive at or before at.
+func SelectArchive(names []string, at time.Time) (string, error) {
+	sorted := sortedArchiveNames(names)
+	var best string
+	for _, name := range sorted {
+		ts, err := time.ParseInLocation(ArchiveNameTimeFormat, name, time.Local)
+		if err != nil {
+			continue
+		}
+		if ts.After(at) {
+			break
+		}
+		best = name
+	}
+	if best == "" {
+		return "", fmt.Errorf("no archive found at or before %s", at.Format(ArchiveNameTimeFormat))
+	}
+	return best, nil
+}
+
+// sortedArchiveNames filters names down to ones that parse as archive
+// timestamps and sorts them chronologically (the time format is chosen so
+// lexicographic order matches chronological order).
+func sortedArchiveNames(names []string) []string {
+	sorted := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, err := time.ParseInLocation(ArchiveNameTimeFormat, name, time.Local); err == nil {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Restore extracts opts.Archive into destFs under destRoot. For
+// incremental backups this transparently pulls unchanged files from the
+// earlier archives referenced in the manifest.
+func (a *Archiver) Restore(destFs afero.Fs, destRoot string, opts RestoreOptions) error {
+	var closers []io.Closer
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+
+	manifest, err := a.readManifest(opts.Archive, &closers)
+	if err != nil {
+		return err
+	}
+
+	if opts.Account != "" {
+		prefix := path.Join("Account", strings.ToUpper(opts.Account)) + "/"
+		filtered := manifest[:0]
+		for _, entry := range manifest {
+			if strings.HasPrefix(entry.RelPath, prefix) {
+				filtered = append(filtered, entry)
+			}
+		}
+		manifest = filtered
+	}
+
+	readers := map[string]*zip.Reader{}
+	bar := progressbar.Default(int64(len(manifest)), opts.Archive)
+	defer bar.Close()
+
+	for _, entry := range manifest {
+		destPath := filepath.Join(destRoot, filepath.FromSlash(entry.RelPath))
+
+		exists, err := afero.Exists(destFs, destPath)
+		if err != nil {
+			return err
+		}
+
+		// Checked before the collision handling below so a dry run never
+		// aborts on a pre-existing file: restoring into a populated WTF
+		// directory is the normal case, and --dry-run exists precisely to
+		// preview that without the error the same restore would raise for real.
+		if opts.DryRun {
+			switch {
+			case exists && opts.SkipExisting:
+				fmt.Fprintf(os.Stderr, ">> Would skip (already exists): %s\n", destPath)
+			case exists && opts.RenameSuffix != "":
+				ext := filepath.Ext(destPath)
+				renamed := strings.TrimSuffix(destPath, ext) + opts.RenameSuffix + ext
+				fmt.Fprintf(os.Stderr, ">> Would restore as: %s\n", renamed)
+			case exists && opts.Overwrite:
+				fmt.Fprintf(os.Stderr, ">> Would overwrite: %s\n", destPath)
+			case exists:
+				fmt.Fprintf(os.Stderr, ">> Would skip (already exists, no --overwrite/--skip-existing/--rename-suffix): %s\n", destPath)
+			default:
+				fmt.Fprintf(os.Stderr, ">> Would restore: %s\n", destPath)
+			}
+			bar.Add(1)
+			continue
+		}
+
+		if exists {
+			switch {
+			case opts.SkipExisting:
+				bar.Add(1)
+				continue
+			case opts.RenameSuffix != "":
+				ext := filepath.Ext(destPath)
+				destPath = strings.TrimSuffix(destPath, ext) + opts.RenameSuffix + ext
+			case !opts.Overwrite:
+				return fmt.Errorf("%s already exists (use --overwrite, --skip-existing, or --rename-suffix)", destPath)
+			}
+		}
+
+		zr, ok := readers[entry.SourceArchive]
+		if !ok {
+			zr, err = a.openArchiveZip(entry.SourceArchive, &closers)
+			if err != nil {
+				return err
+			}
+			readers[entry.SourceArchive] = zr
+		}
+
+		if err := extractEntry(zr, entry.SourcePath, destFs, destPath); err != nil {
+			return err
+		}
+		bar.Add(1)
+	}
+	bar.Finish()
+	return nil
+}
+
+// readManifest returns the full file listing for archiveName: its
+// embedded _manifest.json if present, or (for archives predating
+// incremental support) the archive's own entries.
+func (a *Archiver) readManifest(archiveName string, closers *[]io.Closer) ([]ManifestEntry, error) {
+	zr, err := a.openArchiveZip(archiveName, closers)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		if f.Name != manifestFileName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		var manifest []ManifestEntry
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", manifestFileName, err)
+		}
+		return manifest, nil
+	}
+
+	manifest := make([]ManifestEntry, 0, len(zr.File))
+	for _, f := range zr.File {
+		manifest = append(manifest, ManifestEntry{
+			RelPath:       f.Name,
+			Size:          int64(f.UncompressedSize64),
+			ModTime:       f.Modified,
+			SourceArchive: archiveName,
+			SourcePath:    f.Name,
+		})
+	}
+	return manifest, nil
+}
+
+// openArchiveZip fetches name from the destination backend into a
+// temporary file and opens it as a zip.Reader, which needs io.ReaderAt
+// and so can't be read directly off the backend's streaming Get. The
+// temp file is registered on closers for cleanup by the caller.
+func (a *Archiver) openArchiveZip(name string, closers *[]io.Closer) (*zip.Reader, error) {
+	rc, err := a.dest.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "tirisgarde-restore-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(tmp.Name()) // unlinked but still valid via the open fd until Close
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("downloading %s: %w", name, err)
+	}
+	size, err := tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("reading %s as zip: %w", name, err)
+	}
+	*closers = append(*closers, tmp)
+	return zr, nil
+}
+
+// extractEntry copies a single zip entry out to destFs.
+func extractEntry(zr *zip.Reader, name string, destFs afero.Fs, destPath string) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("reading %s from archive: %w", name, err)
+	}
+	defer f.Close()
+
+	if err := destFs.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	out, err := destFs.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, f)
+	return err
+}
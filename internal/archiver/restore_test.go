@@ -0,0 +1,118 @@
+package archiver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestRestoreExtractsAcrossIncrementalArchives(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = {}"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 30 * 24 * time.Hour, Incremental: true})
+
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("first Archive() error = %v", err)
+	}
+
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/config-cache.wtf", []byte("config"), 0644)
+	if err := a.Archive("/WTF", "2024-01-02_00-00-00.zip"); err != nil {
+		t.Fatalf("second Archive() error = %v", err)
+	}
+
+	destFs := afero.NewMemMapFs()
+	err := a.Restore(destFs, "/restored", RestoreOptions{Archive: "2024-01-02_00-00-00.zip"})
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	for _, relPath := range []string{
+		"/restored/Account/TEST/SavedVariables/Addon.lua",
+		"/restored/Account/TEST/config-cache.wtf",
+	} {
+		ok, err := afero.Exists(destFs, relPath)
+		if err != nil || !ok {
+			t.Errorf("expected %s to be restored (exists=%v, err=%v)", relPath, ok, err)
+		}
+	}
+}
+
+func TestRestoreExtractsContentDeduplicatedAcrossPaths(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/ONE/SavedVariables/Addon.lua", []byte("defaults = {}"), 0644)
+	afero.WriteFile(srcFs, "/WTF/Account/TWO/SavedVariables/Addon.lua", []byte("defaults = {}"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 30 * 24 * time.Hour, Incremental: true})
+
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+
+	destFs := afero.NewMemMapFs()
+	err := a.Restore(destFs, "/restored", RestoreOptions{Archive: "2024-01-01_00-00-00.zip"})
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	for _, relPath := range []string{
+		"/restored/Account/ONE/SavedVariables/Addon.lua",
+		"/restored/Account/TWO/SavedVariables/Addon.lua",
+	} {
+		got, err := afero.ReadFile(destFs, relPath)
+		if err != nil {
+			t.Errorf("expected %s to be restored: %v", relPath, err)
+			continue
+		}
+		if string(got) != "defaults = {}" {
+			t.Errorf("%s content = %q, want %q", relPath, got, "defaults = {}")
+		}
+	}
+}
+
+func TestRestoreDryRunDoesNotFailOnExistingFiles(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	afero.WriteFile(srcFs, "/WTF/Account/TEST/SavedVariables/Addon.lua", []byte("saved = {}"), 0644)
+
+	dest := newMemBackend()
+	a := NewArchiver(srcFs, dest, Options{MaxAge: 30 * 24 * time.Hour})
+	if err := a.Archive("/WTF", "2024-01-01_00-00-00.zip"); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+
+	destFs := afero.NewMemMapFs()
+	afero.WriteFile(destFs, "/restored/Account/TEST/SavedVariables/Addon.lua", []byte("already here"), 0644)
+
+	err := a.Restore(destFs, "/restored", RestoreOptions{Archive: "2024-01-01_00-00-00.zip", DryRun: true})
+	if err != nil {
+		t.Fatalf("Restore() with DryRun against an existing file should not error, got %v", err)
+	}
+
+	got, err := afero.ReadFile(destFs, "/restored/Account/TEST/SavedVariables/Addon.lua")
+	if err != nil || string(got) != "already here" {
+		t.Errorf("dry run must not touch existing files; got %q, %v", got, err)
+	}
+}
+
+func TestLatestAndSelectArchive(t *testing.T) {
+	names := []string{
+		"2024-01-01_00-00-00.zip",
+		"2024-03-01_00-00-00.zip",
+		"2024-02-01_00-00-00.zip",
+		"not-an-archive.txt",
+	}
+
+	latest, err := LatestArchive(names)
+	if err != nil || latest != "2024-03-01_00-00-00.zip" {
+		t.Errorf("LatestArchive() = %q, %v", latest, err)
+	}
+
+	at := time.Date(2024, 2, 15, 0, 0, 0, 0, time.Local)
+	selected, err := SelectArchive(names, at)
+	if err != nil || selected != "2024-02-01_00-00-00.zip" {
+		t.Errorf("SelectArchive() = %q, %v", selected, err)
+	}
+}